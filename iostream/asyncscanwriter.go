@@ -0,0 +1,182 @@
+package iostream
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncOverflowPolicy controls what AsyncScanWriter does when its token channel is
+// full and a new token arrives.
+type AsyncOverflowPolicy int
+
+const (
+	// AsyncBlock blocks the writer (and therefore the Write call feeding it) until
+	// the callback goroutine drains a slot from the channel, or until Deadline
+	// elapses if one is set. This is the default.
+	AsyncBlock AsyncOverflowPolicy = iota
+
+	// AsyncDropOldest discards the oldest buffered token to make room for the new
+	// one, so Write never stalls waiting for a slow callback. Dropped tokens are
+	// counted in Dropped().
+	AsyncDropOldest
+)
+
+// asyncToken is a token queued for delivery by AsyncScanWriter, carrying everything
+// the callback goroutine needs so it never has to touch the ScanWriter internals.
+type asyncToken struct {
+	text       string
+	terminated bool
+}
+
+// AsyncScanWriterOptions configures an AsyncScanWriter created with
+// NewAsyncScanWriter.
+type AsyncScanWriterOptions struct {
+	// Fn is called with every token, from a single dedicated goroutine. Required.
+	Fn TokenFn
+	// Split is the bufio.SplitFunc used to tokenize the stream. Defaults to
+	// bufio.ScanLines.
+	Split bufio.SplitFunc
+	// MaxBufferSize caps the size of a single token. Defaults to
+	// ScanWriterMaxBufferSize.
+	MaxBufferSize int
+	// Overflow selects the ScanWriter's own OverflowPolicy for oversized tokens.
+	// Defaults to OverflowChunk.
+	Overflow OverflowPolicy
+	// ChannelDepth is the number of tokens buffered between the ScanWriter and the
+	// callback goroutine. Defaults to 64.
+	ChannelDepth int
+	// DropPolicy selects what happens when the channel is full. Defaults to
+	// AsyncBlock.
+	DropPolicy AsyncOverflowPolicy
+	// Deadline, when set with DropPolicy AsyncBlock, bounds how long enqueueing a
+	// token waits for room in the channel before the token is dropped and counted in
+	// Dropped(). Zero means wait forever. Ignored with AsyncDropOldest, which never
+	// waits.
+	Deadline time.Duration
+}
+
+// AsyncScanWriter is a ScanWriter whose callback runs on its own goroutine, decoupled
+// from the caller's Write by a bounded channel of tokens. This keeps a slow or
+// blocking callback (a UI render, a remote log sink) from stalling whatever is
+// writing to the stream, e.g. an ssh or exec session reading a remote command's
+// stdout, for as long as the channel has room. Once it is full, AsyncBlock stalls
+// the writer (up to Deadline, if set) while AsyncDropOldest drops tokens instead.
+type AsyncScanWriter struct {
+	sw       *ScanWriter
+	tokens   chan asyncToken
+	dropPol  AsyncOverflowPolicy
+	deadline time.Duration
+	dropped  atomic.Int64
+	doneCh   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewAsyncScanWriter returns a new AsyncScanWriter configured by opts.
+func NewAsyncScanWriter(opts AsyncScanWriterOptions) *AsyncScanWriter {
+	depth := opts.ChannelDepth
+	if depth <= 0 {
+		depth = 64
+	}
+
+	asw := &AsyncScanWriter{
+		tokens:   make(chan asyncToken, depth),
+		dropPol:  opts.DropPolicy,
+		deadline: opts.Deadline,
+		doneCh:   make(chan struct{}),
+	}
+
+	asw.sw = NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            asw.enqueue,
+		Split:         opts.Split,
+		MaxBufferSize: opts.MaxBufferSize,
+		Overflow:      opts.Overflow,
+	})
+
+	go asw.deliver(opts.Fn)
+
+	return asw
+}
+
+// enqueue is the ScanWriter callback: it never calls the user's Fn directly, it only
+// ever pushes onto the bounded channel, so a slow user callback blocks the tokenizer
+// goroutine (and through it the Write caller) only while the channel is full under
+// AsyncBlock, for at most Deadline if one is set.
+func (a *AsyncScanWriter) enqueue(text string, terminated bool) {
+	tok := asyncToken{text: text, terminated: terminated}
+
+	if a.dropPol == AsyncDropOldest {
+		for {
+			select {
+			case a.tokens <- tok:
+				return
+			default:
+			}
+
+			select {
+			case <-a.tokens:
+				a.dropped.Add(1)
+			default:
+			}
+		}
+	}
+
+	if a.deadline <= 0 {
+		a.tokens <- tok
+		return
+	}
+
+	timer := time.NewTimer(a.deadline)
+	defer timer.Stop()
+
+	select {
+	case a.tokens <- tok:
+	case <-timer.C:
+		a.dropped.Add(1)
+	}
+}
+
+// deliver runs on its own goroutine for the lifetime of the AsyncScanWriter, calling
+// fn for every token pulled off the channel.
+func (a *AsyncScanWriter) deliver(fn TokenFn) {
+	defer close(a.doneCh)
+	for tok := range a.tokens {
+		fn(tok.text, tok.terminated)
+	}
+}
+
+// Write writes p to the underlying ScanWriter.
+func (a *AsyncScanWriter) Write(p []byte) (int, error) {
+	return a.sw.Write(p) //nolint:wrapcheck
+}
+
+// Close flushes and closes the underlying ScanWriter, then waits for every already
+// queued token to be delivered before returning. A second call to Close returns
+// io.ErrClosedPipe instead of closing the token channel again, matching
+// ScanWriter.CloseWithError in the same package.
+func (a *AsyncScanWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	err := a.sw.Close()
+	close(a.tokens)
+	<-a.doneCh
+	return err
+}
+
+// Dropped returns the number of tokens discarded, either because the channel was
+// full and DropPolicy is AsyncDropOldest, or because Deadline elapsed while blocked.
+func (a *AsyncScanWriter) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+var _ io.WriteCloser = (*AsyncScanWriter)(nil)
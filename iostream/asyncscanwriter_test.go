@@ -0,0 +1,128 @@
+package iostream
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncScanWriterBlockDeliversEverything(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+
+	asw := NewAsyncScanWriter(AsyncScanWriterOptions{
+		Fn: func(text string, _ bool) {
+			mu.Lock()
+			got = append(got, text)
+			mu.Unlock()
+		},
+		ChannelDepth: 1,
+	})
+
+	if _, err := asw.Write([]byte("a\nb\nc\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := asw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if asw.Dropped() != 0 {
+		t.Fatalf("Dropped() = %d, want 0 under AsyncBlock", asw.Dropped())
+	}
+}
+
+func TestAsyncScanWriterDoubleCloseDoesNotPanic(t *testing.T) {
+	asw := NewAsyncScanWriter(AsyncScanWriterOptions{Fn: func(string, bool) {}})
+
+	if _, err := asw.Write([]byte("a\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := asw.Close(); err != nil {
+		t.Fatalf("first Close: %v", err)
+	}
+
+	if err := asw.Close(); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("second Close err = %v, want io.ErrClosedPipe", err)
+	}
+}
+
+func TestAsyncScanWriterDropOldestNeverBlocksAndCounts(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	asw := NewAsyncScanWriter(AsyncScanWriterOptions{
+		Fn: func(string, bool) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-block // the callback goroutine stalls until the test releases it
+		},
+		ChannelDepth: 1,
+		DropPolicy:   AsyncDropOldest,
+	})
+
+	if _, err := asw.Write([]byte("1\n2\n3\n4\n5\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Write() only guarantees the bytes were handed off and read, not that the
+	// tokenizer goroutine has finished parsing and enqueueing every token from
+	// them. Closing the underlying ScanWriter directly (enqueue never blocks under
+	// AsyncDropOldest, so this can't deadlock on the stalled Fn) is what actually
+	// guarantees every token has been through enqueue before we count drops.
+	if err := asw.sw.Close(); err != nil {
+		t.Fatalf("ScanWriter Close: %v", err)
+	}
+
+	<-started // make sure the slow callback has claimed the channel's one slot
+
+	if asw.Dropped() == 0 {
+		t.Fatal("expected AsyncDropOldest to drop at least one token for a channel depth of 1 behind a stalled callback")
+	}
+
+	close(block)
+	close(asw.tokens)
+	<-asw.doneCh
+}
+
+func TestAsyncScanWriterDeadlineDropsSlowEnqueue(t *testing.T) {
+	release := make(chan struct{})
+
+	asw := NewAsyncScanWriter(AsyncScanWriterOptions{
+		Fn: func(string, bool) {
+			<-release
+		},
+		ChannelDepth: 1,
+		Deadline:     10 * time.Millisecond,
+	})
+
+	if _, err := asw.Write([]byte("1\n2\n3\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for asw.Dropped() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("expected at least one token to be dropped once the enqueue deadline elapsed")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	close(release)
+	_ = asw.Close()
+}
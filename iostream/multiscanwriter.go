@@ -0,0 +1,108 @@
+package iostream
+
+import (
+	"bufio"
+	"io"
+)
+
+// MultiScanWriterOptions configures a MultiScanWriter created with
+// NewMultiScanWriter.
+type MultiScanWriterOptions struct {
+	// Fns are called, in order, with every token. At least one is required.
+	Fns []TokenFn
+	// Split is the bufio.SplitFunc used to tokenize the stream. Defaults to
+	// bufio.ScanLines.
+	Split bufio.SplitFunc
+	// MaxBufferSize caps the size of a single token. Defaults to
+	// ScanWriterMaxBufferSize.
+	MaxBufferSize int
+	// Overflow selects what happens when a token exceeds MaxBufferSize. Defaults to
+	// OverflowChunk.
+	Overflow OverflowPolicy
+}
+
+// NewMultiScanWriter returns a ScanWriter that delivers every token to all of
+// opts.Fns, the way io.MultiWriter tees a single write across several io.Writers but
+// at the tokenized level. A remote command's stdout can be streamed to a logger, a
+// progress UI and an in-memory ring buffer at once without wiring up three separate
+// pipes.
+func NewMultiScanWriter(opts MultiScanWriterOptions) *ScanWriter {
+	fns := make([]TokenFn, len(opts.Fns))
+	copy(fns, opts.Fns)
+
+	return NewScanWriterWithOptions(ScanWriterOptions{
+		Fn: func(text string, terminated bool) {
+			for _, fn := range fns {
+				fn(text, terminated)
+			}
+		},
+		Split:         opts.Split,
+		MaxBufferSize: opts.MaxBufferSize,
+		Overflow:      opts.Overflow,
+	})
+}
+
+// NewMultiScanWriterWriters is a convenience constructor for NewMultiScanWriter that
+// tees tokens to plain io.Writers instead of TokenFns, newline-terminating each one
+// the way fmt.Fprintln would.
+func NewMultiScanWriterWriters(writers ...io.Writer) *ScanWriter {
+	fns := make([]TokenFn, len(writers))
+	for i, w := range writers {
+		w := w
+		fns[i] = func(text string, _ bool) {
+			_, _ = io.WriteString(w, text+"\n")
+		}
+	}
+
+	return NewMultiScanWriter(MultiScanWriterOptions{Fns: fns})
+}
+
+// LevelFn inspects a token and returns the level it should be routed to.
+type LevelFn func(text string) string
+
+// LevelRouterOptions configures a LevelRouter created with NewLevelRouter.
+type LevelRouterOptions struct {
+	// Level classifies each token into a level key. Required.
+	Level LevelFn
+	// Levels maps a level key, as returned by Level, to the callback that handles
+	// tokens of that level. Required.
+	Levels map[string]TokenFn
+	// Default handles tokens whose level has no entry in Levels. If nil, such
+	// tokens are dropped.
+	Default TokenFn
+	// Split is the bufio.SplitFunc used to tokenize the stream. Defaults to
+	// bufio.ScanLines.
+	Split bufio.SplitFunc
+	// MaxBufferSize caps the size of a single token. Defaults to
+	// ScanWriterMaxBufferSize.
+	MaxBufferSize int
+	// Overflow selects what happens when a token exceeds MaxBufferSize. Defaults to
+	// OverflowChunk.
+	Overflow OverflowPolicy
+}
+
+// NewLevelRouter returns a ScanWriter that classifies every token with opts.Level and
+// dispatches it to the matching callback in opts.Levels, mirroring what logrus's
+// WriterLevel does per log level but at the token layer rather than per
+// already-formatted log line.
+func NewLevelRouter(opts LevelRouterOptions) *ScanWriter {
+	levels := make(map[string]TokenFn, len(opts.Levels))
+	for k, v := range opts.Levels {
+		levels[k] = v
+	}
+
+	return NewScanWriterWithOptions(ScanWriterOptions{
+		Fn: func(text string, terminated bool) {
+			fn, ok := levels[opts.Level(text)]
+			if !ok {
+				fn = opts.Default
+			}
+			if fn != nil {
+				fn(text, terminated)
+			}
+		},
+		Split:         opts.Split,
+		MaxBufferSize: opts.MaxBufferSize,
+		Overflow:      opts.Overflow,
+	})
+}
@@ -0,0 +1,98 @@
+package iostream
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMultiScanWriterFansOutToEveryFn(t *testing.T) {
+	var mu sync.Mutex
+	var a, b []string
+
+	sw := NewMultiScanWriter(MultiScanWriterOptions{
+		Fns: []TokenFn{
+			func(text string, _ bool) { mu.Lock(); a = append(a, text); mu.Unlock() },
+			func(text string, _ bool) { mu.Lock(); b = append(b, text); mu.Unlock() },
+		},
+	})
+
+	if _, err := sw.Write([]byte("x\ny\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := []string{"x", "y"}
+	for name, got := range map[string][]string{"a": a, "b": b} {
+		if len(got) != len(want) {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s = %q, want %q", name, got, want)
+			}
+		}
+	}
+}
+
+func TestLevelRouterDispatchesByLevel(t *testing.T) {
+	var errs, infos, other []string
+
+	sw := NewLevelRouter(LevelRouterOptions{
+		Level: func(text string) string {
+			switch {
+			case len(text) >= 5 && text[:5] == "ERROR":
+				return "error"
+			case len(text) >= 4 && text[:4] == "INFO":
+				return "info"
+			default:
+				return "unknown"
+			}
+		},
+		Levels: map[string]TokenFn{
+			"error": func(text string, _ bool) { errs = append(errs, text) },
+			"info":  func(text string, _ bool) { infos = append(infos, text) },
+		},
+		Default: func(text string, _ bool) { other = append(other, text) },
+	})
+
+	if _, err := sw.Write([]byte("INFO starting\nERROR boom\nwat\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if len(infos) != 1 || infos[0] != "INFO starting" {
+		t.Fatalf("infos = %q, want [%q]", infos, "INFO starting")
+	}
+	if len(errs) != 1 || errs[0] != "ERROR boom" {
+		t.Fatalf("errs = %q, want [%q]", errs, "ERROR boom")
+	}
+	if len(other) != 1 || other[0] != "wat" {
+		t.Fatalf("other = %q, want [%q]", other, "wat")
+	}
+}
+
+func TestLevelRouterDropsUnmatchedWithoutDefault(t *testing.T) {
+	var seen []string
+
+	sw := NewLevelRouter(LevelRouterOptions{
+		Level: func(string) string { return "nope" },
+		Levels: map[string]TokenFn{
+			"error": func(text string, _ bool) { seen = append(seen, text) },
+		},
+	})
+
+	if _, err := sw.Write([]byte("anything\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if len(seen) != 0 {
+		t.Fatalf("seen = %q, want no tokens delivered with no matching level and no Default", seen)
+	}
+}
@@ -3,60 +3,162 @@ package iostream
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"sync"
 )
 
-// ScanWriterMaxBufferSize is the maximum size of the ScanWriter buffer. If the buffer
-// is full before the delimiter is encountered, the buffer contents are flushed like
-// the delimiter was encountered.
+// ScanWriterMaxBufferSize is the default maximum size of a ScanWriter token buffer.
+// It is used by NewScanWriter and by NewScanWriterWithOptions when
+// ScanWriterOptions.MaxBufferSize is left unset.
 var ScanWriterMaxBufferSize = 1024 * 1024
 
-// ScanWriter is an io.WriteCloser wrapper for bufio.Scanner.
-// Instead of calling scanner.Scan() like in bufio.Scanner, you write to it and it
-// calls the given callback function with the contents of the internal buffer every
-// time it encounters the given delimiter.
+// ErrTokenTooLong is the error a ScanWriter closes itself with when a token grows
+// past its configured buffer size and OverflowPolicy is OverflowError.
+var ErrTokenTooLong = errors.New("iostream: token too long for buffer")
+
+// ErrTruncatedAtEOF is the error a ScanWriter closes itself with when the split
+// function reports bufio.ErrFinalToken with no token to deliver while bytes are
+// still sitting in the buffer, e.g. a length-prefixed frame cut short by the stream
+// closing mid-write. It is unrelated to OverflowPolicy: the buffer never grew past
+// its limit, the stream just ended before a full token was available.
+var ErrTruncatedAtEOF = errors.New("iostream: stream ended before a final token was complete")
+
+// OverflowPolicy controls what a ScanWriter does when it accumulates a full buffer
+// of data without encountering a delimiter. This is the situation that caused hangs
+// and silently mangled output with bufio.Scanner backed writers such as
+// logrus.Writer(): a line without a delimiter would either block forever waiting for
+// one, or get flushed mid-token with no way for the caller to tell it apart from a
+// real delimiter match.
+type OverflowPolicy int
+
+const (
+	// OverflowChunk flushes the buffered bytes to the callback as if a delimiter had
+	// been found, then keeps reading the rest of the oversized token as further
+	// chunks. This is the historical ScanWriter behavior and the default for
+	// NewScanWriter.
+	OverflowChunk OverflowPolicy = iota
+
+	// OverflowTruncate delivers the buffered bytes once, then discards everything up
+	// to and including the next delimiter. ScanWriterOptions.OverflowFn, if set, is
+	// called with the number of bytes discarded.
+	OverflowTruncate
+
+	// OverflowError closes the ScanWriter with ErrTokenTooLong instead of delivering
+	// the oversized token.
+	OverflowError
+)
+
+// TokenFn is called for every token a ScanWriter produces. terminated is true when
+// the token was returned by the split function (a delimiter match, or a final
+// unterminated token the split function hands back at end of stream), and false
+// when the ScanWriter flushed the bytes itself: because the buffer reached its
+// maximum size (see OverflowPolicy), or because the stream ended with bytes the
+// split function did not return as a token.
+type TokenFn func(text string, terminated bool)
+
+// CallbackFn is a function that takes a string as an argument and returns nothing.
 //
-// You must call Close() to flush the remaining buffer contents to the scanner.
+// Deprecated: use TokenFn via NewScanWriterWithOptions, which also reports whether
+// the token was delimiter-terminated or flushed due to OverflowPolicy.
+type CallbackFn func(string)
+
+// ScanWriterOptions configures a ScanWriter created with NewScanWriterWithOptions.
+type ScanWriterOptions struct {
+	// Fn is called for every token produced. Required.
+	Fn TokenFn
+	// Split is the bufio.SplitFunc used to tokenize the stream. Defaults to
+	// bufio.ScanLines.
+	Split bufio.SplitFunc
+	// MaxBufferSize caps the size of a single token. Defaults to
+	// ScanWriterMaxBufferSize.
+	MaxBufferSize int
+	// Overflow selects what happens when a token exceeds MaxBufferSize. Defaults to
+	// OverflowChunk.
+	Overflow OverflowPolicy
+	// OverflowFn, if set, is called with the number of bytes discarded every time
+	// OverflowTruncate drops data waiting for the next delimiter. Ignored by other
+	// policies.
+	OverflowFn func(discarded int)
+}
+
+// ScanWriter is an io.WriteCloser that tokenizes everything written to it and calls
+// a callback for every token, the way bufio.Scanner does for a reader. Instead of
+// calling Scan() yourself, you Write() to it and the callback fires every time a
+// delimiter (or, depending on OverflowPolicy, the buffer limit) is reached.
+//
+// You must call Close() to flush the remaining buffer contents.
 type ScanWriter struct {
-	fn      CallbackFn
-	pipeR   *io.PipeReader
-	pipeW   *io.PipeWriter
-	scanner *bufio.Scanner
-	once    sync.Once
+	fn            TokenFn
+	split         bufio.SplitFunc
+	maxBufferSize int
+	overflow      OverflowPolicy
+	overflowFn    func(discarded int)
+
+	pipeR *io.PipeReader
+	pipeW *io.PipeWriter
+	once  sync.Once
+
 	closed  bool
 	closeCh chan struct{}
-}
 
-// CallbackFn is a function that takes a string as an argument and returns nothing.
-type CallbackFn func(string)
+	mu       sync.Mutex
+	lastText string
+	err      error
+}
 
-// NewScanWriter returns a new ScanWriter.
+// NewScanWriter returns a new ScanWriter using bufio.ScanLines and OverflowChunk,
+// matching the historical ScanWriter behavior: on overflow, the buffer contents are
+// flushed as if the delimiter had been encountered.
 func NewScanWriter(fn CallbackFn) io.WriteCloser {
-	sw := &ScanWriter{fn: fn, closeCh: make(chan struct{})}
+	return NewScanWriterWithOptions(ScanWriterOptions{
+		Fn: func(text string, _ bool) { fn(text) },
+	})
+}
+
+// NewScanWriterWithOptions returns a new ScanWriter configured by opts. Unset fields
+// fall back to the same defaults as NewScanWriter: bufio.ScanLines, OverflowChunk and
+// ScanWriterMaxBufferSize.
+func NewScanWriterWithOptions(opts ScanWriterOptions) *ScanWriter {
+	split := opts.Split
+	if split == nil {
+		split = bufio.ScanLines
+	}
+
+	maxBufferSize := opts.MaxBufferSize
+	if maxBufferSize <= 0 {
+		maxBufferSize = ScanWriterMaxBufferSize
+	}
+
+	sw := &ScanWriter{
+		fn:            opts.Fn,
+		split:         split,
+		maxBufferSize: maxBufferSize,
+		overflow:      opts.Overflow,
+		overflowFn:    opts.OverflowFn,
+		closeCh:       make(chan struct{}),
+	}
 	sw.pipeR, sw.pipeW = io.Pipe()
-	sw.scanner = bufio.NewScanner(sw.pipeR)
-	sw.scanner.Buffer(nil, ScanWriterMaxBufferSize)
+
 	return sw
 }
 
-// Write writes the given bytes to the scanner.
+// Write writes the given bytes to the writer, tokenizing them in the background.
 func (w *ScanWriter) Write(p []byte) (int, error) {
 	if w.closed {
 		return 0, io.ErrUnexpectedEOF
 	}
 	w.once.Do(func() {
 		go func() {
-			for w.scanner.Scan() {
-				w.fn(w.scanner.Text())
-			}
-			close(w.closeCh)
+			defer close(w.closeCh)
+			w.runLoop()
 		}()
 	})
 	return w.pipeW.Write(p) //nolint:wrapcheck
 }
 
-// Close closes the writer and the underlying pipe. It returns the last error encountered by the scanner.
+// Close closes the writer and the underlying pipe. It returns the last error
+// encountered while tokenizing.
 func (w *ScanWriter) Close() error {
 	return w.CloseWithError(nil)
 }
@@ -74,20 +176,168 @@ func (w *ScanWriter) CloseWithError(reason error) error {
 
 	<-w.closeCh
 
-	return w.scanner.Err() //nolint:wrapcheck
+	return w.Err()
 }
 
-// Err returns the last error encountered by the scanner.
+// Err returns the last error encountered while tokenizing, including ErrTokenTooLong
+// if the writer was configured with OverflowError and a token exceeded the buffer.
 func (w *ScanWriter) Err() error {
-	return w.scanner.Err() //nolint:wrapcheck
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
 }
 
-// Split sets the split function for the scanner. see [bufio.Scanner](https://pkg.go.dev/bufio#Scanner)
+// Split sets the split function used to tokenize the stream. It must be called
+// before the first Write. See [bufio.Scanner](https://pkg.go.dev/bufio#Scanner).
 func (w *ScanWriter) Split(split bufio.SplitFunc) {
-	w.scanner.Split(split)
+	w.split = split
 }
 
-// Text returns the most recent token generated by a call to Scan as a newly allocated string holding its bytes.
+// Text returns the most recently produced token.
 func (w *ScanWriter) Text() string {
-	return w.scanner.Text()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastText
+}
+
+// setErr records err as the writer's terminal error and closes the read side of the
+// pipe so that further writes unblock instead of hanging.
+func (w *ScanWriter) setErr(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	_ = w.pipeR.CloseWithError(err)
+}
+
+// deliver runs fn with the given token, recording it as the most recent token first.
+func (w *ScanWriter) deliver(token []byte, terminated bool) {
+	text := string(token)
+	w.mu.Lock()
+	w.lastText = text
+	w.mu.Unlock()
+	w.fn(text, terminated)
+}
+
+// runLoop reads from the pipe and tokenizes it using split, calling deliver for
+// every token. It deliberately does not use bufio.Scanner: Scanner has no way to
+// tell a caller whether a token ended because the split function matched a
+// delimiter or because Scanner's internal buffer was full, which is exactly the
+// distinction OverflowPolicy needs to make.
+func (w *ScanWriter) runLoop() {
+	maxSize := w.maxBufferSize
+	initialCap := 4096
+	if initialCap > maxSize {
+		initialCap = maxSize
+	}
+	buf := make([]byte, 0, initialCap)
+
+	var eof bool
+	discarding := false
+	discarded := 0
+
+	for {
+		advance, token, splitErr := w.split(buf, eof)
+		if splitErr != nil && !errors.Is(splitErr, bufio.ErrFinalToken) {
+			w.setErr(splitErr)
+			return
+		}
+
+		if errors.Is(splitErr, bufio.ErrFinalToken) && advance == 0 && token == nil {
+			// The split function is telling us to stop: there is no token to
+			// deliver and none of the buffered bytes should be treated as one
+			// either. This is not a buffer overflow (the buffer may be far from
+			// maxSize), so it must not be routed through OverflowPolicy/
+			// ErrTokenTooLong or handed to the callback as a leftover chunk.
+			if discarding && w.overflowFn != nil {
+				w.overflowFn(discarded + len(buf))
+			}
+			if len(buf) > 0 && !discarding {
+				err := splitErr
+				if err == bufio.ErrFinalToken { //nolint:errorlint // bare sentinel means the split func gave us no extra context
+					err = ErrTruncatedAtEOF
+				}
+				w.setErr(err)
+			}
+			return
+		}
+
+		if advance > 0 {
+			if discarding {
+				discarded += advance
+				discarding = false
+				if w.overflowFn != nil {
+					w.overflowFn(discarded)
+				}
+			} else if token != nil {
+				w.deliver(token, true)
+			}
+			buf = buf[advance:]
+			if errors.Is(splitErr, bufio.ErrFinalToken) {
+				return
+			}
+			continue
+		}
+
+		if eof {
+			if discarding && w.overflowFn != nil {
+				w.overflowFn(discarded + len(buf))
+			}
+			if len(buf) > 0 && !discarding {
+				if w.overflow == OverflowError {
+					w.setErr(ErrTokenTooLong)
+				} else {
+					w.deliver(buf, false)
+				}
+			}
+			return
+		}
+
+		if len(buf) >= maxSize {
+			switch w.overflow {
+			case OverflowError:
+				w.setErr(ErrTokenTooLong)
+				return
+			case OverflowTruncate:
+				if !discarding {
+					w.deliver(buf, false)
+					discarding = true
+					discarded = 0
+				} else {
+					discarded += len(buf)
+				}
+				buf = buf[:0]
+			case OverflowChunk:
+				fallthrough
+			default:
+				w.deliver(buf, false)
+				buf = buf[:0]
+			}
+			continue
+		}
+
+		if len(buf) == cap(buf) {
+			newCap := cap(buf) * 2
+			if newCap == 0 {
+				newCap = 4096
+			}
+			if newCap > maxSize {
+				newCap = maxSize
+			}
+			grown := make([]byte, len(buf), newCap)
+			copy(grown, buf)
+			buf = grown
+		}
+
+		n, err := w.pipeR.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				w.setErr(err)
+				return
+			}
+			eof = true
+		}
+	}
 }
@@ -0,0 +1,157 @@
+package iostream
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScanWriterOverflowChunk(t *testing.T) {
+	var got []string
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            func(text string, terminated bool) { got = append(got, text) },
+		MaxBufferSize: 4,
+	})
+
+	if _, err := sw.Write([]byte("abcdefgh")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := []string{"abcd", "efgh"}
+	if !equalStrings(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestScanWriterOverflowTruncate(t *testing.T) {
+	var got []string
+	var dropped int
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            func(text string, terminated bool) { got = append(got, text) },
+		MaxBufferSize: 4,
+		Overflow:      OverflowTruncate,
+		OverflowFn:    func(discarded int) { dropped = discarded },
+	})
+
+	if _, err := sw.Write([]byte("abcdefgh\nxy\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := []string{"abcd", "xy"}
+	if !equalStrings(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if dropped == 0 {
+		t.Fatal("expected OverflowFn to report discarded bytes")
+	}
+}
+
+func TestScanWriterOverflowError(t *testing.T) {
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            func(string, bool) {},
+		MaxBufferSize: 4,
+		Overflow:      OverflowError,
+	})
+
+	// Once the overflow trips, the reader side closes with ErrTokenTooLong, which
+	// unblocks Write with that same error rather than letting it finish feeding the
+	// rest of the (already doomed) token - that's expected, not a test failure.
+	_, writeErr := sw.Write([]byte("abcdefgh\n"))
+	closeErr := sw.Close()
+
+	if !errors.Is(writeErr, ErrTokenTooLong) && !errors.Is(closeErr, ErrTokenTooLong) {
+		t.Fatalf("writeErr = %v, closeErr = %v, want one of them to be ErrTokenTooLong", writeErr, closeErr)
+	}
+}
+
+func TestScanWriterTerminatedFlag(t *testing.T) {
+	type tok struct {
+		text       string
+		terminated bool
+	}
+	var got []tok
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            func(text string, term bool) { got = append(got, tok{text, term}) },
+		MaxBufferSize: 4,
+	})
+
+	// "abcd" and "efgh" are only delivered because they hit MaxBufferSize before a
+	// delimiter is found (terminated=false); "" and "xy" are delivered because the
+	// split func found a real newline (terminated=true).
+	if _, err := sw.Write([]byte("abcdefgh\nxy\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := []tok{{"abcd", false}, {"efgh", false}, {"", true}, {"xy", true}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// finalTokenAtEOF is a minimal split func standing in for anything (like
+// SplitLengthPrefixed) that reports bufio.ErrFinalToken with no token once the
+// stream ends mid-record: it treats "::" as a complete record and, at EOF with
+// leftover bytes that don't end in "::", refuses to deliver them.
+func finalTokenAtEOF(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := strings.Index(string(data), "::"); i >= 0 {
+		return i + 2, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return 0, nil, bufio.ErrFinalToken
+	}
+	return 0, nil, nil
+}
+
+func TestScanWriterTruncatedFinalTokenIsNotOverflow(t *testing.T) {
+	var got []string
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:            func(text string, _ bool) { got = append(got, text) },
+		Split:         finalTokenAtEOF,
+		MaxBufferSize: 1024,
+		Overflow:      OverflowError,
+	})
+
+	if _, err := sw.Write([]byte("aa::bb")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	err := sw.Close()
+
+	if errors.Is(err, ErrTokenTooLong) {
+		t.Fatalf("Close err = %v, want ErrTruncatedAtEOF, not ErrTokenTooLong (buffer never reached MaxBufferSize)", err)
+	}
+	if !errors.Is(err, ErrTruncatedAtEOF) {
+		t.Fatalf("Close err = %v, want ErrTruncatedAtEOF", err)
+	}
+
+	want := []string{"aa"}
+	if !equalStrings(got, want) {
+		t.Fatalf("leftover bytes must not be delivered to the callback, got %q", got)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
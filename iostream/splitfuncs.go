@@ -0,0 +1,158 @@
+package iostream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"regexp"
+)
+
+// ErrIncompleteFrame is returned (wrapped together with bufio.ErrFinalToken, so
+// errors.Is still matches both) by SplitLengthPrefixed when the stream ends with a
+// short length prefix or a payload cut off before reaching its declared length. A
+// Docker stdcopy-style multiplexed stream ending mid-frame because the remote
+// process was killed or the connection dropped is the normal way this happens, not
+// an edge case, so it gets its own sentinel rather than being folded into a generic
+// EOF.
+var ErrIncompleteFrame = errors.New("iostream: stream ended mid-frame")
+
+// SplitJSONLines is a bufio.SplitFunc that emits one complete JSON value per token,
+// regardless of whether it is written as a single line or pretty-printed across
+// several. It tracks brace/bracket nesting and string/escape state byte by byte, so
+// braces inside string literals don't confuse the depth count.
+//
+// Leading whitespace between values is skipped. A top-level JSON scalar (a bare
+// number, string, bool or null, with no enclosing {} or []) is also recognized as a
+// complete token once followed by whitespace or EOF.
+func SplitJSONLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for start < len(data) && isJSONSpace(data[start]) {
+		start++
+	}
+	if start == len(data) {
+		return start, nil, nil
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(data); i++ {
+		c := data[i]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, data[start : i+1], nil
+			}
+		default:
+			if depth == 0 && isJSONSpace(c) {
+				// End of a bare scalar token (number/string/bool/null).
+				return i, data[start:i], nil
+			}
+		}
+	}
+
+	if atEOF {
+		if depth == 0 && start < len(data) {
+			return len(data), data[start:], nil
+		}
+		if start < len(data) {
+			// Unterminated value at EOF: hand back whatever we have rather than
+			// hanging, same as bufio.ScanLines does for a final unterminated line.
+			return len(data), data[start:], nil
+		}
+	}
+
+	return start, nil, nil
+}
+
+func isJSONSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\r', '\n':
+		return true
+	default:
+		return false
+	}
+}
+
+// SplitLengthPrefixed returns a bufio.SplitFunc for frames shaped like
+// [width-byte length][length bytes of payload], the framing docker's stdcopy
+// multiplexing and similar wire protocols use. width must be 2, 4 or 8. The emitted
+// token is the payload only, with the length prefix stripped.
+func SplitLengthPrefixed(order binary.ByteOrder, width int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if len(data) < width {
+			if atEOF && len(data) > 0 {
+				return 0, nil, errors.Join(ErrIncompleteFrame, bufio.ErrFinalToken)
+			}
+			return 0, nil, nil
+		}
+
+		var length uint64
+		switch width {
+		case 2:
+			length = uint64(order.Uint16(data))
+		case 4:
+			length = uint64(order.Uint32(data))
+		case 8:
+			length = order.Uint64(data)
+		default:
+			return 0, nil, bufio.ErrFinalToken
+		}
+
+		frameEnd := width + int(length)
+		if frameEnd < 0 || frameEnd > len(data) {
+			if atEOF {
+				return 0, nil, errors.Join(ErrIncompleteFrame, bufio.ErrFinalToken)
+			}
+			return 0, nil, nil
+		}
+
+		return frameEnd, data[width:frameEnd], nil
+	}
+}
+
+// ansiEscape matches CSI sequences (ESC '[' ... final byte) and OSC sequences
+// (ESC ']' ... BEL or ESC '\') as commonly emitted by terminal-oriented CLIs such as
+// k0s, containerd and docker when their output is attached to a TTY.
+var ansiEscape = regexp.MustCompile("\x1b(?:\\[[0-9;?]*[ -/]*[@-~]|\\][^\x07\x1b]*(?:\x07|\x1b\\\\))")
+
+// SplitLinesStripANSI returns a bufio.SplitFunc that behaves like bufio.ScanLines but
+// additionally strips ANSI CSI/OSC escape sequences from each line. If sideChannel is
+// non-nil, it is called with the raw (un-stripped) line for every token, so callers
+// that want to preserve the original formatting (e.g. to replay it to a real
+// terminal) can still get at it.
+func SplitLinesStripANSI(sideChannel func(raw []byte)) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = bufio.ScanLines(data, atEOF)
+		if err != nil || token == nil {
+			return advance, token, err
+		}
+
+		if sideChannel != nil {
+			raw := make([]byte, len(token))
+			copy(raw, token)
+			sideChannel(raw)
+		}
+
+		return advance, ansiEscape.ReplaceAll(token, nil), nil
+	}
+}
@@ -0,0 +1,180 @@
+package iostream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestSplitJSONLines(t *testing.T) {
+	scanner := bufio.NewScanner(bytes.NewReader([]byte(
+		`{"a":1}` + "\n" +
+			"{\n  \"b\": 2,\n  \"c\": [1, 2]\n}\n" +
+			`"bare-string"` + "\n" +
+			"42\n",
+	)))
+	scanner.Split(SplitJSONLines)
+
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	want := []string{
+		`{"a":1}`,
+		"{\n  \"b\": 2,\n  \"c\": [1, 2]\n}",
+		`"bare-string"`,
+		"42",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %q, want %d %q", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("token %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSplitJSONLinesBraceInString(t *testing.T) {
+	scanner := bufio.NewScanner(bytes.NewReader([]byte(`{"a":"}{"}` + "\n")))
+	scanner.Split(SplitJSONLines)
+
+	if !scanner.Scan() {
+		t.Fatalf("expected a token, scan error: %v", scanner.Err())
+	}
+	if got, want := scanner.Text(), `{"a":"}{"}`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitLengthPrefixed(t *testing.T) {
+	var buf bytes.Buffer
+	for _, frame := range []string{"hello", "", "world"} {
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(frame)))
+		buf.WriteString(frame)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	scanner.Split(SplitLengthPrefixed(binary.BigEndian, 4))
+
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	want := []string{"hello", "", "world"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSplitLengthPrefixedIncompleteFrame(t *testing.T) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, uint32(10))
+	buf.WriteString("abc") // declares 10 bytes of payload, only provides 3
+
+	split := SplitLengthPrefixed(binary.BigEndian, 4)
+	advance, token, err := split(buf.Bytes(), true)
+
+	if advance != 0 || token != nil {
+		t.Fatalf("got advance=%d token=%q, want 0, nil (no bytes handed off raw)", advance, token)
+	}
+	if !errors.Is(err, ErrIncompleteFrame) {
+		t.Fatalf("err = %v, want ErrIncompleteFrame", err)
+	}
+	if !errors.Is(err, bufio.ErrFinalToken) {
+		t.Fatalf("err = %v, want it to also satisfy bufio.ErrFinalToken", err)
+	}
+}
+
+func TestSplitLengthPrefixedIncompletePrefix(t *testing.T) {
+	split := SplitLengthPrefixed(binary.BigEndian, 4)
+	advance, token, err := split([]byte{0x00, 0x01}, true)
+
+	if advance != 0 || token != nil {
+		t.Fatalf("got advance=%d token=%q, want 0, nil", advance, token)
+	}
+	if !errors.Is(err, ErrIncompleteFrame) {
+		t.Fatalf("err = %v, want ErrIncompleteFrame", err)
+	}
+}
+
+// TestScanWriterSurfacesIncompleteLengthPrefixedFrame exercises SplitLengthPrefixed
+// through a real ScanWriter rather than calling the split func directly, since that
+// is the path ErrIncompleteFrame actually needs to survive: runLoop's bare
+// `err == bufio.ErrFinalToken` check must fall through for the joined error instead
+// of collapsing it into the generic ErrTruncatedAtEOF.
+func TestScanWriterSurfacesIncompleteLengthPrefixedFrame(t *testing.T) {
+	var got []string
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn:    func(text string, _ bool) { got = append(got, text) },
+		Split: SplitLengthPrefixed(binary.BigEndian, 4),
+	})
+
+	var stream bytes.Buffer
+	_ = binary.Write(&stream, binary.BigEndian, uint32(5))
+	stream.WriteString("hello")
+	_ = binary.Write(&stream, binary.BigEndian, uint32(10))
+	stream.WriteString("abc") // declares 10 bytes, stream ends after only 3
+
+	if _, err := sw.Write(stream.Bytes()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	err := sw.Close()
+
+	wantTokens := []string{"hello"}
+	if len(got) != len(wantTokens) || got[0] != wantTokens[0] {
+		t.Fatalf("got %q, want %q (the truncated second frame must not be delivered)", got, wantTokens)
+	}
+	if !errors.Is(err, ErrIncompleteFrame) {
+		t.Fatalf("Close err = %v, want ErrIncompleteFrame", err)
+	}
+	if errors.Is(err, ErrTruncatedAtEOF) {
+		t.Fatalf("Close err = %v, should not collapse to the generic ErrTruncatedAtEOF now that SplitLengthPrefixed reports a more specific error", err)
+	}
+}
+
+func TestSplitLinesStripANSI(t *testing.T) {
+	var rawLines [][]byte
+	split := SplitLinesStripANSI(func(raw []byte) { rawLines = append(rawLines, raw) })
+
+	input := "\x1b[32mok\x1b[0m\nplain\n"
+	scanner := bufio.NewScanner(bytes.NewReader([]byte(input)))
+	scanner.Split(split)
+
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	want := []string{"ok", "plain"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if len(rawLines) != 2 || !bytes.Contains(rawLines[0], []byte("\x1b[32m")) {
+		t.Fatalf("side channel should receive the raw, un-stripped line, got %q", rawLines)
+	}
+}
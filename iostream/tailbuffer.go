@@ -0,0 +1,162 @@
+package iostream
+
+import (
+	"bytes"
+	"sync"
+)
+
+// TailBuffer is an io.Writer that keeps only the last N bytes (or, configured by
+// line count, the last N lines) written to it, in a fixed-size ring. It never
+// allocates per Write and never grows past its configured capacity, unlike
+// bytes.Buffer, which makes it safe to attach to a long-running or unbounded stream
+// purely to capture diagnostics for when something goes wrong.
+type TailBuffer struct {
+	mu sync.Mutex
+
+	buf  []byte // fixed-size ring
+	pos  int    // next write position in buf
+	full bool   // true once the ring has wrapped at least once
+
+	maxLines int // 0 means byte-capacity mode
+}
+
+// DefaultTailBufferSize is the ring size NewTailBuffer falls back to when n is not
+// positive.
+var DefaultTailBufferSize = 64 * 1024
+
+// DefaultTailLines and DefaultTailLineLen are the defaults NewTailLineBuffer falls
+// back to when lines or maxLineLen, respectively, are not positive.
+var (
+	DefaultTailLines   = 200
+	DefaultTailLineLen = 1024
+)
+
+// NewTailBuffer returns a TailBuffer that retains the last n bytes written to it. If
+// n is not positive, DefaultTailBufferSize is used instead.
+func NewTailBuffer(n int) *TailBuffer {
+	if n <= 0 {
+		n = DefaultTailBufferSize
+	}
+	return &TailBuffer{buf: make([]byte, n)}
+}
+
+// NewTailLineBuffer returns a TailBuffer that retains the last lines lines written to
+// it, each up to maxLineLen bytes, instead of a fixed byte budget. Internally this is
+// still a single fixed-size ring of maxLineLen*lines bytes; line boundaries are found
+// by scanning for '\n' when Lines() is called. Non-positive lines or maxLineLen fall
+// back to DefaultTailLines and DefaultTailLineLen respectively.
+func NewTailLineBuffer(lines, maxLineLen int) *TailBuffer {
+	if lines <= 0 {
+		lines = DefaultTailLines
+	}
+	if maxLineLen <= 0 {
+		maxLineLen = DefaultTailLineLen
+	}
+	return &TailBuffer{
+		buf:      make([]byte, lines*maxLineLen),
+		maxLines: lines,
+	}
+}
+
+// Write implements io.Writer, copying p into the ring one (possibly wrapped) chunk at
+// a time. It never fails.
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	if len(t.buf) == 0 {
+		return n, nil
+	}
+
+	// Only the final len(t.buf) bytes of p can possibly survive in the ring.
+	if len(p) > len(t.buf) {
+		p = p[len(p)-len(t.buf):]
+		t.full = true
+	}
+
+	for len(p) > 0 {
+		c := copy(t.buf[t.pos:], p)
+		p = p[c:]
+		t.pos += c
+		if t.pos == len(t.buf) {
+			t.pos = 0
+			t.full = true
+		}
+	}
+
+	return n, nil
+}
+
+// Snapshot returns a copy of the buffered bytes in write order (oldest first).
+func (t *TailBuffer) Snapshot() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		out := make([]byte, t.pos)
+		copy(out, t.buf[:t.pos])
+		return out
+	}
+
+	out := make([]byte, len(t.buf))
+	n := copy(out, t.buf[t.pos:])
+	copy(out[n:], t.buf[:t.pos])
+	return out
+}
+
+// Lines returns the buffered content split into lines (oldest first), with any
+// trailing partial line included. If the buffer was created with NewTailLineBuffer,
+// only the most recent maxLines lines are returned, regardless of how many bytes
+// they occupy - a ring sized for maxLines long lines can easily hold far more than
+// maxLines short ones, so the cap is based on the actual line count, not on whether
+// the byte ring has wrapped.
+func (t *TailBuffer) Lines() []string {
+	snap := t.Snapshot()
+	if len(snap) == 0 {
+		return nil
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(snap, []byte("\n")), []byte("\n"))
+
+	t.mu.Lock()
+	maxLines, full := t.maxLines, t.full
+	t.mu.Unlock()
+
+	if full && maxLines > 0 && len(lines) > 1 {
+		// The ring has wrapped, so the oldest entry is almost certainly a partial
+		// line cut off at the wrap point; it's not a real line, drop it.
+		lines = lines[1:]
+	}
+
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = string(l)
+	}
+	return out
+}
+
+// NewTailScanWriter returns a ScanWriter that tokenizes its input the same as
+// NewScanWriter, while also mirroring everything written to it into a TailBuffer of
+// the last n bytes. This is meant for cases like a failed remote k0s install command,
+// where rig-k0s wants to attach the last few KB of interleaved stdout/stderr to the
+// returned error without holding the whole (potentially huge) output in memory.
+func NewTailScanWriter(n int, fn CallbackFn) (*ScanWriter, *TailBuffer) {
+	tail := NewTailBuffer(n)
+
+	sw := NewScanWriterWithOptions(ScanWriterOptions{
+		Fn: func(text string, terminated bool) {
+			_, _ = tail.Write([]byte(text))
+			if terminated {
+				_, _ = tail.Write([]byte("\n"))
+			}
+			fn(text)
+		},
+	})
+
+	return sw, tail
+}
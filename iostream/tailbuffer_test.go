@@ -0,0 +1,113 @@
+package iostream
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTailBufferNonPositiveSizeDoesNotPanic(t *testing.T) {
+	for _, n := range []int{0, -1, -1000} {
+		tb := NewTailBuffer(n)
+		if len(tb.buf) != DefaultTailBufferSize {
+			t.Fatalf("NewTailBuffer(%d): ring size = %d, want DefaultTailBufferSize (%d)", n, len(tb.buf), DefaultTailBufferSize)
+		}
+	}
+
+	for _, lines := range []int{0, -1} {
+		tb := NewTailLineBuffer(lines, 0)
+		if tb.maxLines != DefaultTailLines {
+			t.Fatalf("NewTailLineBuffer(%d, 0): maxLines = %d, want DefaultTailLines (%d)", lines, tb.maxLines, DefaultTailLines)
+		}
+		if len(tb.buf) != DefaultTailLines*DefaultTailLineLen {
+			t.Fatalf("NewTailLineBuffer(%d, 0): ring size = %d, want %d", lines, len(tb.buf), DefaultTailLines*DefaultTailLineLen)
+		}
+	}
+}
+
+func TestTailBufferWraparound(t *testing.T) {
+	tb := NewTailBuffer(4)
+
+	_, _ = tb.Write([]byte("ab"))
+	_, _ = tb.Write([]byte("cdef")) // wraps: only the last 4 bytes survive
+
+	got := tb.Snapshot()
+	want := []byte("cdef")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Snapshot() = %q, want %q", got, want)
+	}
+}
+
+func TestTailBufferWriteLargerThanRing(t *testing.T) {
+	tb := NewTailBuffer(4)
+
+	_, _ = tb.Write([]byte("0123456789"))
+
+	got := tb.Snapshot()
+	want := []byte("6789")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Snapshot() = %q, want %q", got, want)
+	}
+}
+
+func TestTailBufferLines(t *testing.T) {
+	tb := NewTailBuffer(64)
+
+	_, _ = tb.Write([]byte("one\ntwo\nthree\n"))
+
+	got := tb.Lines()
+	want := []string{"one", "two", "three"}
+	if len(got) != len(want) {
+		t.Fatalf("Lines() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Lines()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTailLineBufferCapsToMaxLinesWithoutWrapping(t *testing.T) {
+	// 5 lines * 1024 bytes/line is far more than 1000 short "N\n" lines will ever
+	// occupy, so the byte ring never wraps - Lines() must still cap to the last 5
+	// lines based on line count, not on whether the ring has filled up.
+	tb := NewTailLineBuffer(5, 1024)
+
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(tb, "%d\n", i)
+	}
+
+	if tb.full {
+		t.Fatalf("test setup invalid: expected the byte ring to still have room, got full=true")
+	}
+
+	got := tb.Lines()
+	want := []string{"995", "996", "997", "998", "999"}
+	if len(got) != len(want) {
+		t.Fatalf("Lines() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Lines()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTailScanWriterCapturesTail(t *testing.T) {
+	sw, tail := NewTailScanWriter(8, func(string) {})
+
+	if _, err := sw.Write([]byte("line-one\nline-two\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	snap := tail.Snapshot()
+	if len(snap) != 8 {
+		t.Fatalf("Snapshot() length = %d, want 8 (the ring size)", len(snap))
+	}
+	if !bytes.HasSuffix(snap, []byte("two\n")) {
+		t.Fatalf("Snapshot() = %q, want it to end with the most recently written bytes", snap)
+	}
+}